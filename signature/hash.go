@@ -0,0 +1,56 @@
+/*
+ * Minio Cloud Storage, (C) 2015, 2016 Minio, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package signature
+
+import (
+	"crypto/sha256"
+	"hash"
+)
+
+// hash256Backends holds every SHA-256 implementation available to this
+// build. The "stdlib" entry (crypto/sha256) is always present; a
+// SIMD-accelerated "simd" entry (github.com/minio/sha256-simd, selecting
+// SHA-NI/AVX512/ARM64 where available) is registered by hash_simd.go when
+// yig is built with the sha256_simd build tag.
+var hash256Backends = map[string]func() hash.Hash{
+	"stdlib": sha256.New,
+}
+
+// activeHash256Backend is the backend sumHMAC and sum256 route all SigV4
+// payload/chunk hashing through, selectable at runtime via
+// SetHash256Backend.
+var activeHash256Backend = hash256Backends["stdlib"]
+
+// New256 returns a new hash.Hash using the currently active SHA-256
+// backend.
+func New256() hash.Hash {
+	return activeHash256Backend()
+}
+
+// SetHash256Backend switches the SHA-256 implementation used for SigV4
+// hashing to the named backend ("stdlib", or "simd" when yig was built with
+// the sha256_simd tag), e.g. from a config flag at startup. It reports
+// false and leaves the active backend unchanged if name is not available in
+// this build.
+func SetHash256Backend(name string) bool {
+	backend, ok := hash256Backends[name]
+	if !ok {
+		return false
+	}
+	activeHash256Backend = backend
+	return true
+}
@@ -0,0 +1,56 @@
+/*
+ * Minio Cloud Storage, (C) 2015, 2016 Minio, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package signature
+
+import (
+	"crypto/rand"
+	"testing"
+)
+
+// BenchmarkHash256Backends compares every SHA-256 backend registered in
+// hash256Backends (just "stdlib" by default; also "simd" when built with
+// -tags sha256_simd) across payload sizes representative of a small PUT (4
+// MiB), a large PUT (64 MiB), and a single STREAMING-AWS4-HMAC-SHA256-PAYLOAD
+// chunk (64 KiB, the chunk size most SDKs default to).
+func BenchmarkHash256Backends(b *testing.B) {
+	sizes := []struct {
+		name  string
+		bytes int
+	}{
+		{"4MiB", 4 << 20},
+		{"64MiB", 64 << 20},
+		{"StreamingChunk64KiB", 64 << 10},
+	}
+
+	for _, size := range sizes {
+		data := make([]byte, size.bytes)
+		if _, err := rand.Read(data); err != nil {
+			b.Fatal(err)
+		}
+		for name, backend := range hash256Backends {
+			b.Run(size.name+"/"+name, func(b *testing.B) {
+				b.SetBytes(int64(size.bytes))
+				b.ResetTimer()
+				for i := 0; i < b.N; i++ {
+					h := backend()
+					h.Write(data)
+					h.Sum(nil)
+				}
+			})
+		}
+	}
+}
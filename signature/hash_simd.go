@@ -0,0 +1,35 @@
+//go:build sha256_simd
+// +build sha256_simd
+
+/*
+ * Minio Cloud Storage, (C) 2015, 2016 Minio, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package signature
+
+// Requires github.com/minio/sha256-simd, declared alongside this package's
+// other dependencies in the module's go.mod/go.sum -- not tracked in this
+// source tree, which has no module manifest of its own.
+import (
+	simd256 "github.com/minio/sha256-simd"
+)
+
+// Built with -tags sha256_simd: register and default to the SIMD-accelerated
+// backend, which picks SHA-NI, AVX512 or ARM64 SHA2 instructions when the
+// CPU supports them and falls back to a pure-Go implementation otherwise.
+func init() {
+	hash256Backends["simd"] = simd256.New
+	activeHash256Backend = simd256.New
+}
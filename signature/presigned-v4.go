@@ -0,0 +1,85 @@
+/*
+ * Minio Cloud Storage, (C) 2015, 2016 Minio, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package signature
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+
+	. "git.letv.cn/yig/yig/error"
+)
+
+// IsPresignedURL reports whether req carries an AWS SigV4 presigned
+// signature (X-Amz-Signature in the query string) rather than an
+// Authorization header.
+func IsPresignedURL(req *http.Request) bool {
+	return req.URL.Query().Get("X-Amz-Signature") != ""
+}
+
+// reencodeCredentialAccessKey extracts the access-key segment of a
+// presigned request's X-Amz-Credential value and round-trips it through
+// getURLEncodedName, so access keys containing '+', '=' or other reserved
+// characters re-canonicalize to exactly what the client signed.
+//
+// The scope that follows the access key is always exactly
+// "<date>/<region>/<service>/aws4_request" -- four fixed fields -- so a
+// well-formed credential splits into exactly five '/'-separated fields.
+// More fields means the access-key segment itself contains a '/', which
+// would make the scope separators ambiguous; fewer means the scope is
+// incomplete. Both are rejected rather than mis-split.
+func reencodeCredentialAccessKey(credential string) (string, error) {
+	parts := strings.Split(credential, "/")
+	if len(parts) != 5 {
+		return "", ErrSignatureDoesNotMatch
+	}
+	accessKey, scope := parts[0], strings.Join(parts[1:], "/")
+	return getURLEncodedName(accessKey) + "/" + scope, nil
+}
+
+// CanonicalizePresignedQuery rebuilds req.URL.RawQuery to match the query
+// string the client actually signed. Go's http server URL-decodes the query
+// before handlers ever see it, so naively re-encoding it with
+// url.Values.Encode() produces a different canonical query than the
+// UriEncode AWS clients sign with -- most visibly for X-Amz-Credential,
+// whose access-key segment needs to be round-tripped through
+// getURLEncodedName rather than Go's own escaping rules.
+func CanonicalizePresignedQuery(req *http.Request) error {
+	query := req.URL.Query()
+	keys := make([]string, 0, len(query))
+	for k := range query {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	parts := make([]string, 0, len(query))
+	for _, k := range keys {
+		for _, v := range query[k] {
+			encodedValue := getURLEncodedName(v)
+			if k == "X-Amz-Credential" {
+				reencoded, err := reencodeCredentialAccessKey(v)
+				if err != nil {
+					return err
+				}
+				encodedValue = reencoded
+			}
+			parts = append(parts, getURLEncodedName(k)+"="+encodedValue)
+		}
+	}
+	req.URL.RawQuery = strings.Join(parts, "&")
+	return nil
+}
@@ -0,0 +1,37 @@
+/*
+ * Minio Cloud Storage, (C) 2015, 2016 Minio, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package signature
+
+import "testing"
+
+func TestSetRegionsAcceptsConfiguredRegionAndAliases(t *testing.T) {
+	saved := regions
+	t.Cleanup(func() { regions = saved })
+
+	SetRegions([]string{"cn-north-1"}, map[string][]string{
+		"cn-north-1": {"cn-beijing-1"},
+	})
+
+	for _, region := range []string{"cn-north-1", "cn-beijing-1", defaultRegion} {
+		if !isValidRegion(region) {
+			t.Fatalf("isValidRegion(%q) = false, want true", region)
+		}
+	}
+	if isValidRegion("mars-west-1") {
+		t.Fatalf("isValidRegion(%q) = true, want false", "mars-west-1")
+	}
+}
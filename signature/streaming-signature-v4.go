@@ -0,0 +1,214 @@
+/*
+ * Minio Cloud Storage, (C) 2015, 2016 Minio, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package signature
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	. "git.letv.cn/yig/yig/error"
+)
+
+// http Header "x-amz-content-sha256" == "STREAMING-AWS4-HMAC-SHA256-PAYLOAD"
+// indicates that the request body is chunked, aws-chunked encoded, with
+// each chunk individually signed.
+const (
+	StreamingContentSHA256 = "STREAMING-AWS4-HMAC-SHA256-PAYLOAD"
+	streamingSignAlgorithm = "AWS4-HMAC-SHA256-PAYLOAD"
+	iso8601Format          = "20060102T150405Z"
+)
+
+var emptySHA256Hex = hex.EncodeToString(sum256([]byte{}))
+
+// maxChunkSize bounds the size field of a single aws-chunked chunk, as a
+// sanity check against a malformed or hostile size field before it is used
+// as a slice length.
+const maxChunkSize = 1 << 30 // 1 GiB
+
+// parseChunkSize decodes an aws-chunked hex size field, rejecting negative
+// values (strconv.ParseInt accepts a leading '-', which AWS's wire format
+// never produces) and anything implausibly large.
+func parseChunkSize(field string) (int64, error) {
+	size, err := strconv.ParseInt(field, 16, 64)
+	if err != nil || size < 0 || size > maxChunkSize {
+		return 0, ErrSignatureDoesNotMatch
+	}
+	return size, nil
+}
+
+// wrapUnexpectedEOF turns a bare io.EOF encountered while a chunked reader
+// is still expecting more chunks into io.ErrUnexpectedEOF, so a
+// body that is truncated (or simply never sends its terminating
+// zero-length chunk) is distinguishable from a legitimately complete,
+// fully-verified stream -- callers like io.Copy treat a bare io.EOF as
+// success.
+func wrapUnexpectedEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// IsStreamingPayload returns true when the request's x-amz-content-sha256
+// header declares a STREAMING-AWS4-HMAC-SHA256-PAYLOAD body.
+func IsStreamingPayload(req *http.Request) bool {
+	return req.Header.Get("x-amz-content-sha256") == StreamingContentSHA256
+}
+
+// getScope builds the "date/region/s3/aws4_request" credential scope used in
+// both the seed signature and every chunk signature.
+func getScope(t time.Time, region string) string {
+	return strings.Join([]string{
+		t.Format("20060102"),
+		region,
+		"s3",
+		"aws4_request",
+	}, "/")
+}
+
+// s3ChunkedReader decodes an aws-chunked STREAMING-AWS4-HMAC-SHA256-PAYLOAD
+// body, verifying each chunk's signature against the signature of the chunk
+// before it, and hands back the decoded chunk data to its caller.
+type s3ChunkedReader struct {
+	reader     *bufio.Reader
+	closer     io.Closer
+	signingKey []byte
+	region     string
+	date       time.Time
+
+	prevSignature string
+	chunk         bytes.Buffer
+	eof           bool
+	err           error
+}
+
+// NewSignV4ChunkedReader wraps req.Body, which must be a
+// STREAMING-AWS4-HMAC-SHA256-PAYLOAD body, returning a reader that yields the
+// decoded payload and fails with ErrSignatureDoesNotMatch as soon as a chunk
+// signature does not chain correctly from seedSignature.
+func NewSignV4ChunkedReader(req *http.Request, seedSignature string, signingKey []byte, region string, date time.Time) io.ReadCloser {
+	return &s3ChunkedReader{
+		reader:        bufio.NewReader(req.Body),
+		closer:        req.Body,
+		signingKey:    signingKey,
+		region:        region,
+		date:          date,
+		prevSignature: seedSignature,
+	}
+}
+
+func (cr *s3ChunkedReader) Close() error {
+	return cr.closer.Close()
+}
+
+func (cr *s3ChunkedReader) Read(buf []byte) (n int, err error) {
+	if cr.err != nil {
+		return 0, cr.err
+	}
+	for cr.chunk.Len() == 0 {
+		if cr.eof {
+			cr.err = io.EOF
+			return 0, io.EOF
+		}
+		if err = cr.readChunk(); err != nil {
+			cr.err = err
+			return 0, err
+		}
+	}
+	return cr.chunk.Read(buf)
+}
+
+// readChunk consumes one "<hex-size>;chunk-signature=<sig>\r\n<data>\r\n"
+// chunk off the wire, verifies its signature and, unless it is the
+// terminating zero-length chunk, buffers its data for Read.
+func (cr *s3ChunkedReader) readChunk() error {
+	header, err := cr.reader.ReadString('\n')
+	if err != nil {
+		return wrapUnexpectedEOF(err)
+	}
+	header = strings.TrimSuffix(strings.TrimSuffix(header, "\n"), "\r")
+
+	parts := strings.SplitN(header, ";", 2)
+	if len(parts) != 2 || !strings.HasPrefix(parts[1], "chunk-signature=") {
+		return ErrSignatureDoesNotMatch
+	}
+	size, err := parseChunkSize(parts[0])
+	if err != nil {
+		return err
+	}
+	gotSignature := strings.TrimPrefix(parts[1], "chunk-signature=")
+
+	data := make([]byte, size)
+	if size > 0 {
+		if _, err = io.ReadFull(cr.reader, data); err != nil {
+			return wrapUnexpectedEOF(err)
+		}
+	}
+	if _, err = io.ReadFull(cr.reader, make([]byte, 2)); err != nil { // trailing CRLF
+		return wrapUnexpectedEOF(err)
+	}
+
+	if gotSignature != cr.chunkSignature(data) {
+		return ErrSignatureDoesNotMatch
+	}
+	cr.prevSignature = gotSignature
+
+	if size == 0 {
+		cr.eof = true
+		return nil
+	}
+	cr.chunk.Write(data)
+	return nil
+}
+
+// chunkSignature computes HMAC-SHA256(signingKey, StringToSign) for one
+// chunk, chained off the previous chunk's (or the seed) signature.
+func (cr *s3ChunkedReader) chunkSignature(chunk []byte) string {
+	stringToSign := strings.Join([]string{
+		streamingSignAlgorithm,
+		cr.date.Format(iso8601Format),
+		getScope(cr.date, cr.region),
+		cr.prevSignature,
+		emptySHA256Hex,
+		hex.EncodeToString(sum256(chunk)),
+	}, "\n")
+	return hex.EncodeToString(sumHMAC(cr.signingKey, []byte(stringToSign)))
+}
+
+// SetUpSignatureV4ChunkedBody replaces req.Body with a verifying
+// s3ChunkedReader when the request declares a streaming signed payload, so
+// that object handlers downstream can read decoded, authenticated content
+// transparently. It also rewrites req.ContentLength from
+// x-amz-decoded-content-length, since the wire Content-Length includes the
+// chunk framing overhead.
+func SetUpSignatureV4ChunkedBody(req *http.Request, seedSignature string, signingKey []byte, region string, date time.Time) {
+	if !IsStreamingPayload(req) {
+		return
+	}
+	req.Body = NewSignV4ChunkedReader(req, seedSignature, signingKey, region, date)
+	if decoded := req.Header.Get("x-amz-decoded-content-length"); decoded != "" {
+		if length, err := strconv.ParseInt(decoded, 10, 64); err == nil {
+			req.ContentLength = length
+		}
+	}
+}
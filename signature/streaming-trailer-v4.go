@@ -0,0 +1,297 @@
+/*
+ * Minio Cloud Storage, (C) 2015, 2016 Minio, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package signature
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/hex"
+	"hash"
+	"hash/crc32"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	. "git.letv.cn/yig/yig/error"
+)
+
+// http Header "x-amz-content-sha256" sentinel values for a chunked body
+// terminated by an x-amz-trailer checksum trailer, instead of a
+// fully-hashed or per-chunk-only-signed payload.
+const (
+	// StreamingUnsignedPayloadTrailer bodies use the same chunk framing as
+	// StreamingContentSHA256 but without per-chunk signatures.
+	StreamingUnsignedPayloadTrailer = "STREAMING-UNSIGNED-PAYLOAD-TRAILER"
+	// StreamingSignedPayloadTrailer bodies sign every chunk, same as
+	// StreamingContentSHA256, and additionally sign the trailer itself via
+	// x-amz-trailer-signature.
+	StreamingSignedPayloadTrailer = "STREAMING-AWS4-HMAC-SHA256-PAYLOAD-TRAILER"
+
+	trailerSignAlgorithm = "AWS4-HMAC-SHA256-TRAILER"
+)
+
+// IsTrailerPayload returns true when the request declares a chunked body
+// terminated by an x-amz-trailer checksum trailer, signed or unsigned.
+func IsTrailerPayload(req *http.Request) bool {
+	switch req.Header.Get("x-amz-content-sha256") {
+	case StreamingUnsignedPayloadTrailer, StreamingSignedPayloadTrailer:
+		return true
+	}
+	return false
+}
+
+// newTrailerChecksum returns the hash.Hash that accumulates the decoded
+// body for the algorithm named by the x-amz-trailer header, e.g.
+// "x-amz-checksum-crc32c".
+func newTrailerChecksum(trailerName string) (hash.Hash, error) {
+	switch strings.TrimPrefix(trailerName, "x-amz-checksum-") {
+	case "crc32":
+		return crc32.NewIEEE(), nil
+	case "crc32c":
+		return crc32.New(crc32.MakeTable(crc32.Castagnoli)), nil
+	case "sha1":
+		return sha1.New(), nil
+	case "sha256":
+		return New256(), nil
+	}
+	return nil, ErrInvalidChecksum
+}
+
+// s3TrailerChunkedReader decodes an aws-chunked body terminated by an
+// x-amz-trailer checksum trailer, verifying, as it is consumed: each
+// chunk's signature (signed variant only), the trailer checksum against the
+// decoded bytes, and the trailer's own x-amz-trailer-signature (signed
+// variant only).
+type s3TrailerChunkedReader struct {
+	reader      *bufio.Reader
+	closer      io.Closer
+	signed      bool
+	signingKey  []byte
+	region      string
+	date        time.Time
+	trailerName string
+
+	prevSignature string
+	checksum      hash.Hash
+	chunk         bytes.Buffer
+	eof           bool
+	err           error
+
+	// Checksum is the base64-encoded, verified trailer value, populated
+	// once the reader reaches EOF. The object layer can store it alongside
+	// the object and return it on GET via x-amz-checksum-*.
+	Checksum string
+}
+
+// NewSignV4TrailerChunkedReader wraps req.Body, which must be a
+// STREAMING-UNSIGNED-PAYLOAD-TRAILER or STREAMING-AWS4-HMAC-SHA256-PAYLOAD-TRAILER
+// body, returning a reader that yields the decoded payload and fails with
+// ErrSignatureDoesNotMatch or ErrInvalidChecksum as soon as a chunk
+// signature, trailer checksum or trailer signature does not verify.
+func NewSignV4TrailerChunkedReader(req *http.Request, seedSignature string, signingKey []byte, region string, date time.Time) (*s3TrailerChunkedReader, error) {
+	trailerName := req.Header.Get("x-amz-trailer")
+	checksum, err := newTrailerChecksum(trailerName)
+	if err != nil {
+		return nil, err
+	}
+	return &s3TrailerChunkedReader{
+		reader:        bufio.NewReader(req.Body),
+		closer:        req.Body,
+		signed:        req.Header.Get("x-amz-content-sha256") == StreamingSignedPayloadTrailer,
+		signingKey:    signingKey,
+		region:        region,
+		date:          date,
+		trailerName:   trailerName,
+		prevSignature: seedSignature,
+		checksum:      checksum,
+	}, nil
+}
+
+func (cr *s3TrailerChunkedReader) Close() error {
+	return cr.closer.Close()
+}
+
+func (cr *s3TrailerChunkedReader) Read(buf []byte) (n int, err error) {
+	if cr.err != nil {
+		return 0, cr.err
+	}
+	for cr.chunk.Len() == 0 {
+		if cr.eof {
+			cr.err = io.EOF
+			return 0, io.EOF
+		}
+		if err = cr.readChunk(); err != nil {
+			cr.err = err
+			return 0, err
+		}
+	}
+	return cr.chunk.Read(buf)
+}
+
+// readChunk consumes one chunk off the wire -- "<hex-size>\r\n<data>\r\n"
+// when unsigned, "<hex-size>;chunk-signature=<sig>\r\n<data>\r\n" when
+// signed -- and, on the terminating zero-length chunk, hands off to
+// readTrailer. Unlike the non-trailer streaming format, the zero-length
+// chunk header here is immediately followed by the trailer line, with no
+// blank CRLF in between, so readTrailer -- not this function -- owns
+// reading past it.
+func (cr *s3TrailerChunkedReader) readChunk() error {
+	header, err := cr.reader.ReadString('\n')
+	if err != nil {
+		return wrapUnexpectedEOF(err)
+	}
+	header = strings.TrimSuffix(strings.TrimSuffix(header, "\n"), "\r")
+
+	sizeField, gotSignature := header, ""
+	if cr.signed {
+		parts := strings.SplitN(header, ";", 2)
+		if len(parts) != 2 || !strings.HasPrefix(parts[1], "chunk-signature=") {
+			return ErrSignatureDoesNotMatch
+		}
+		sizeField = parts[0]
+		gotSignature = strings.TrimPrefix(parts[1], "chunk-signature=")
+	}
+	size, err := parseChunkSize(sizeField)
+	if err != nil {
+		return err
+	}
+
+	if size == 0 {
+		if cr.signed && gotSignature != cr.chunkSignature(nil) {
+			return ErrSignatureDoesNotMatch
+		}
+		cr.prevSignature = gotSignature
+		return cr.readTrailer()
+	}
+
+	data := make([]byte, size)
+	if _, err = io.ReadFull(cr.reader, data); err != nil {
+		return wrapUnexpectedEOF(err)
+	}
+	if _, err = io.ReadFull(cr.reader, make([]byte, 2)); err != nil { // trailing CRLF
+		return wrapUnexpectedEOF(err)
+	}
+
+	if cr.signed {
+		if gotSignature != cr.chunkSignature(data) {
+			return ErrSignatureDoesNotMatch
+		}
+		cr.prevSignature = gotSignature
+	}
+
+	cr.checksum.Write(data)
+	cr.chunk.Write(data)
+	return nil
+}
+
+func (cr *s3TrailerChunkedReader) chunkSignature(chunk []byte) string {
+	stringToSign := strings.Join([]string{
+		streamingSignAlgorithm,
+		cr.date.Format(iso8601Format),
+		getScope(cr.date, cr.region),
+		cr.prevSignature,
+		emptySHA256Hex,
+		hex.EncodeToString(sum256(chunk)),
+	}, "\n")
+	return hex.EncodeToString(sumHMAC(cr.signingKey, []byte(stringToSign)))
+}
+
+// readTrailer consumes the "<trailer-name>:<base64-value>\r\n" line that
+// terminates the chunk stream -- and, for the signed variant, the
+// following "x-amz-trailer-signature:<sig>\r\n" line -- verifying the
+// declared checksum against the bytes streamed through cr.checksum and, for
+// the signed variant, the trailer's own signature.
+func (cr *s3TrailerChunkedReader) readTrailer() error {
+	trailerLine, err := cr.reader.ReadString('\n')
+	if err != nil {
+		return wrapUnexpectedEOF(err)
+	}
+	trailerLine = strings.TrimSuffix(strings.TrimSuffix(trailerLine, "\n"), "\r")
+
+	parts := strings.SplitN(trailerLine, ":", 2)
+	if len(parts) != 2 || parts[0] != cr.trailerName {
+		return ErrInvalidChecksum
+	}
+	value := parts[1]
+	if value != base64.StdEncoding.EncodeToString(cr.checksum.Sum(nil)) {
+		return ErrInvalidChecksum
+	}
+
+	if cr.signed {
+		sigLine, err := cr.reader.ReadString('\n')
+		if err != nil {
+			return wrapUnexpectedEOF(err)
+		}
+		sigLine = strings.TrimSuffix(strings.TrimSuffix(sigLine, "\n"), "\r")
+		sigParts := strings.SplitN(sigLine, ":", 2)
+		if len(sigParts) != 2 || sigParts[0] != "x-amz-trailer-signature" {
+			return ErrSignatureDoesNotMatch
+		}
+		if sigParts[1] != cr.trailerSignature(trailerLine) {
+			return ErrSignatureDoesNotMatch
+		}
+	}
+
+	// The trailer block, like the chunk block before it, is terminated by a
+	// trailing CRLF.
+	if _, err = io.ReadFull(cr.reader, make([]byte, 2)); err != nil {
+		return wrapUnexpectedEOF(err)
+	}
+
+	cr.Checksum = value
+	cr.eof = true
+	return nil
+}
+
+func (cr *s3TrailerChunkedReader) trailerSignature(trailerLine string) string {
+	stringToSign := strings.Join([]string{
+		trailerSignAlgorithm,
+		cr.date.Format(iso8601Format),
+		getScope(cr.date, cr.region),
+		cr.prevSignature,
+		hex.EncodeToString(sum256([]byte(trailerLine))),
+	}, "\n")
+	return hex.EncodeToString(sumHMAC(cr.signingKey, []byte(stringToSign)))
+}
+
+// SetUpTrailerChunkedBody replaces req.Body with a verifying
+// s3TrailerChunkedReader when the request declares a trailer-terminated
+// streaming payload, rewriting req.ContentLength from
+// x-amz-decoded-content-length the same way SetUpSignatureV4ChunkedBody
+// does. The returned reader's Checksum field is populated once the object
+// handler has fully drained the body, for the object layer to persist and
+// later surface on GET via x-amz-checksum-*.
+func SetUpTrailerChunkedBody(req *http.Request, seedSignature string, signingKey []byte, region string, date time.Time) (*s3TrailerChunkedReader, error) {
+	if !IsTrailerPayload(req) {
+		return nil, nil
+	}
+	reader, err := NewSignV4TrailerChunkedReader(req, seedSignature, signingKey, region, date)
+	if err != nil {
+		return nil, err
+	}
+	req.Body = reader
+	if decoded := req.Header.Get("x-amz-decoded-content-length"); decoded != "" {
+		if length, err := strconv.ParseInt(decoded, 10, 64); err == nil {
+			req.ContentLength = length
+		}
+	}
+	return reader, nil
+}
@@ -0,0 +1,188 @@
+/*
+ * Minio Cloud Storage, (C) 2015, 2016 Minio, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package signature
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	. "git.letv.cn/yig/yig/error"
+)
+
+// plainChunk builds one "<hex-size>\r\n<data>\r\n" chunk, the non-terminal
+// framing of unsigned trailer bodies (signed ones use chunkWireBytes).
+func plainChunk(data []byte) []byte {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "%x\r\n", len(data))
+	buf.Write(data)
+	buf.WriteString("\r\n")
+	return buf.Bytes()
+}
+
+// crc32cBase64 computes the base64-encoded CRC-32C checksum newTrailerChecksum
+// produces for "x-amz-checksum-crc32c".
+func crc32cBase64(data []byte) string {
+	sum := crc32.Checksum(data, crc32.MakeTable(crc32.Castagnoli))
+	var b [4]byte
+	b[0], b[1], b[2], b[3] = byte(sum>>24), byte(sum>>16), byte(sum>>8), byte(sum)
+	return base64.StdEncoding.EncodeToString(b[:])
+}
+
+// trailerSignatureFor replicates s3TrailerChunkedReader.trailerSignature to
+// build well-formed signed-trailer test fixtures.
+func trailerSignatureFor(signingKey []byte, region string, date time.Time, prevSig, trailerLine string) string {
+	stringToSign := strings.Join([]string{
+		trailerSignAlgorithm,
+		date.Format(iso8601Format),
+		getScope(date, region),
+		prevSig,
+		hex.EncodeToString(sum256([]byte(trailerLine))),
+	}, "\n")
+	return hex.EncodeToString(sumHMAC(signingKey, []byte(stringToSign)))
+}
+
+func TestSignV4TrailerChunkedReaderUnsignedRoundTrip(t *testing.T) {
+	data := []byte("hello")
+
+	var body bytes.Buffer
+	body.Write(plainChunk(data))
+	body.WriteString("0\r\n") // terminal chunk: no blank CRLF before the trailer line
+	fmt.Fprintf(&body, "x-amz-checksum-crc32c:%s\r\n", crc32cBase64(data))
+	body.WriteString("\r\n")
+
+	req, err := http.NewRequest(http.MethodPut, "https://example.com/obj", bytes.NewReader(body.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("x-amz-content-sha256", StreamingUnsignedPayloadTrailer)
+	req.Header.Set("x-amz-trailer", "x-amz-checksum-crc32c")
+
+	reader, err := NewSignV4TrailerChunkedReader(req, "", nil, "us-east-1", time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reader.Close()
+
+	got, err := ioutil.ReadAll(reader)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if string(got) != "hello" {
+		t.Fatalf("got %q, want %q", got, "hello")
+	}
+	if want := crc32cBase64(data); reader.Checksum != want {
+		t.Fatalf("Checksum = %q, want %q", reader.Checksum, want)
+	}
+}
+
+func TestSignV4TrailerChunkedReaderSignedRoundTrip(t *testing.T) {
+	signingKey := []byte("secret")
+	region := "us-east-1"
+	date := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	seedSig := "seed"
+	data := []byte("hello")
+
+	sig1, chunk1 := chunkWireBytes(signingKey, region, date, seedSig, data)
+	sig2 := signChunk(signingKey, region, date, sig1, nil)
+	trailerLine := fmt.Sprintf("x-amz-checksum-crc32c:%s", crc32cBase64(data))
+	trailerSig := trailerSignatureFor(signingKey, region, date, sig2, trailerLine)
+
+	var body bytes.Buffer
+	body.Write(chunk1)
+	fmt.Fprintf(&body, "0;chunk-signature=%s\r\n", sig2) // terminal chunk: no blank CRLF before the trailer line
+	body.WriteString(trailerLine + "\r\n")
+	fmt.Fprintf(&body, "x-amz-trailer-signature:%s\r\n", trailerSig)
+	body.WriteString("\r\n")
+
+	req, err := http.NewRequest(http.MethodPut, "https://example.com/obj", bytes.NewReader(body.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("x-amz-content-sha256", StreamingSignedPayloadTrailer)
+	req.Header.Set("x-amz-trailer", "x-amz-checksum-crc32c")
+
+	reader, err := NewSignV4TrailerChunkedReader(req, seedSig, signingKey, region, date)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reader.Close()
+
+	got, err := ioutil.ReadAll(reader)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if string(got) != "hello" {
+		t.Fatalf("got %q, want %q", got, "hello")
+	}
+}
+
+func TestSignV4TrailerChunkedReaderRejectsTruncatedBody(t *testing.T) {
+	data := []byte("hello")
+
+	req, err := http.NewRequest(http.MethodPut, "https://example.com/obj", bytes.NewReader(plainChunk(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("x-amz-content-sha256", StreamingUnsignedPayloadTrailer)
+	req.Header.Set("x-amz-trailer", "x-amz-checksum-crc32c")
+
+	reader, err := NewSignV4TrailerChunkedReader(req, "", nil, "us-east-1", time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reader.Close()
+
+	if _, err := ioutil.ReadAll(reader); err != io.ErrUnexpectedEOF {
+		t.Fatalf("error = %v, want %v", err, io.ErrUnexpectedEOF)
+	}
+}
+
+func TestSignV4TrailerChunkedReaderRejectsBadChecksum(t *testing.T) {
+	data := []byte("hello")
+
+	var body bytes.Buffer
+	body.Write(plainChunk(data))
+	body.WriteString("0\r\n")
+	fmt.Fprintf(&body, "x-amz-checksum-crc32c:%s\r\n", base64.StdEncoding.EncodeToString([]byte("bogus!!!")))
+	body.WriteString("\r\n")
+
+	req, err := http.NewRequest(http.MethodPut, "https://example.com/obj", bytes.NewReader(body.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("x-amz-content-sha256", StreamingUnsignedPayloadTrailer)
+	req.Header.Set("x-amz-trailer", "x-amz-checksum-crc32c")
+
+	reader, err := NewSignV4TrailerChunkedReader(req, "", nil, "us-east-1", time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reader.Close()
+
+	if _, err := ioutil.ReadAll(reader); err != ErrInvalidChecksum {
+		t.Fatalf("error = %v, want %v", err, ErrInvalidChecksum)
+	}
+}
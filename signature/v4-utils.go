@@ -18,7 +18,6 @@ package signature
 
 import (
 	"crypto/hmac"
-	"crypto/sha256"
 	"encoding/hex"
 	. "git.letv.cn/yig/yig/error"
 	"net/http"
@@ -29,23 +28,20 @@ import (
 
 // http Header "x-amz-content-sha256" == "UNSIGNED-PAYLOAD" indicates that the
 // client did not calculate sha256 of the payload.
-const (
-	UnsignedPayload = "UNSIGNED-PAYLOAD"
-	REGION          = "cn-bj-1"
-)
+const UnsignedPayload = "UNSIGNED-PAYLOAD"
 
-// isValidRegion - verify if incoming region value is valid with configured Region.
-// TODO
-func isValidRegion(reqRegion string) bool {
-	if reqRegion == "" {
-		return true
-	}
-	return reqRegion == REGION
+// sumHMAC calculate hmac between two input byte array, using the active
+// SHA-256 backend (see hash.go).
+func sumHMAC(key []byte, data []byte) []byte {
+	hash := hmac.New(New256, key)
+	hash.Write(data)
+	return hash.Sum(nil)
 }
 
-// sumHMAC calculate hmac between two input byte array.
-func sumHMAC(key []byte, data []byte) []byte {
-	hash := hmac.New(sha256.New, key)
+// sum256 calculates the sha256 sum of the input byte array, using the
+// active SHA-256 backend (see hash.go).
+func sum256(data []byte) []byte {
+	hash := New256()
 	hash.Write(data)
 	return hash.Sum(nil)
 }
@@ -91,16 +87,21 @@ func getURLEncodedName(name string) string {
 	return encodedName
 }
 
-// getCanonicalHeaders extract signed headers from Authorization header and form the required string:
+// extractSignedHeaders builds the http.Header map of every header a request
+// actually signed, keyed the same way req.Header is, so callers other than
+// getCanonicalHeaders (chunked/trailer re-hashing, policy condition checks,
+// ...) can work off the same structured view.
 //
-// Lowercase(<HeaderName1>)+":"+Trim(<value>)+"\n"
-// Lowercase(<HeaderName2>)+":"+Trim(<value>)+"\n"
-// ...
-// Lowercase(<HeaderNameN>)+":"+Trim(<value>)+"\n"
-//
-// Return ErrMissingRequiredSignedHeader if a header is missing in http header but exists in signedHeaders
-func getCanonicalHeaders(signedHeaders []string, req *http.Request) (string, error) {
-	canonicalHeaders := ""
+// Returns ErrMissingRequiredSignedHeader if a signed header is absent from
+// the request, ErrUnsignedHeaders if "host" is not part of signedHeaders, or
+// if the request is neither presigned nor UNSIGNED-PAYLOAD and
+// "x-amz-content-sha256" is not part of signedHeaders, and
+// ErrSignatureDoesNotMatch if the request's credential scope names a region
+// yig is not configured to serve (see region.go).
+func extractSignedHeaders(signedHeaders []string, req *http.Request) (http.Header, error) {
+	extractedHeaders := make(http.Header)
+	hostSigned := false
+	contentSHA256Signed := false
 	for _, header := range signedHeaders {
 		values, ok := req.Header[http.CanonicalHeaderKey(header)]
 		// Golang http server strips off 'Expect' header, if the
@@ -128,11 +129,48 @@ func getCanonicalHeaders(signedHeaders []string, req *http.Request) (string, err
 		if header == "host" {
 			values = []string{req.Host}
 			ok = true
+			hostSigned = true
+		}
+		if header == "x-amz-content-sha256" {
+			contentSHA256Signed = true
 		}
 		if !ok {
-			return "", ErrMissingRequiredSignedHeader
+			return nil, ErrMissingRequiredSignedHeader
 		}
+		extractedHeaders[http.CanonicalHeaderKey(header)] = values
+	}
+	if !hostSigned {
+		return nil, ErrUnsignedHeaders
+	}
+	if !contentSHA256Signed && !IsPresignedURL(req) && req.Header.Get("x-amz-content-sha256") != UnsignedPayload {
+		return nil, ErrUnsignedHeaders
+	}
+	scope, err := extractCredentialScope(req)
+	if err != nil {
+		return nil, err
+	}
+	if err := validateCredentialRegion(scope); err != nil {
+		return nil, err
+	}
+	return extractedHeaders, nil
+}
+
+// getCanonicalHeaders extracts signed headers via extractSignedHeaders and
+// forms the required string, in signedHeaders order:
+//
+// Lowercase(<HeaderName1>)+":"+Trim(<value>)+"\n"
+// Lowercase(<HeaderName2>)+":"+Trim(<value>)+"\n"
+// ...
+// Lowercase(<HeaderNameN>)+":"+Trim(<value>)+"\n"
+func getCanonicalHeaders(signedHeaders []string, req *http.Request) (string, error) {
+	extractedHeaders, err := extractSignedHeaders(signedHeaders, req)
+	if err != nil {
+		return "", err
+	}
+	canonicalHeaders := ""
+	for _, header := range signedHeaders {
 		canonicalHeaders += header + ":"
+		values := extractedHeaders[http.CanonicalHeaderKey(header)]
 		for idx, v := range values {
 			if idx > 0 {
 				canonicalHeaders += ","